@@ -2,22 +2,70 @@ package cmd
 
 import (
 	"bufio"
-	"log"
+	"bytes"
+	"errors"
+	"fmt"
 	"os"
 	"os/exec"
 	"runtime"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
 )
 
+var (
+	lsofFullCommand bool
+	lsofNet         bool
+	lsofByPort      bool
+	lsofMerge       bool
+	lsofShowPeers   bool
+	lsofExclude     bool
+	lsofExcludeRoot bool
+	lsofSystem      []string
+	lsofWarn        int
+	lsofWithSize    bool
+	lsofByParent    bool
+	lsofUptime      bool
+)
+
+// systemProcesses are the command names excluded by --exclude-system unless
+// overridden with --system-processes.
+var systemProcesses = []string{
+	"kernel_task",
+	"launchd",
+	"init",
+	"kthreadd",
+	"systemd",
+	"systemd-journald",
+	"systemd-logind",
+	"systemd-resolved",
+	"systemd-udevd",
+}
+
+// commandWidth is the width lsof truncates command names to by default.
+const commandWidth = 9
+
+// maxPeers is the number of remote endpoints shown per process.
+const maxPeers = 3
+
 type Process struct {
 	PID   string
 	Name  string
 	Count int
+	// Path is the executable path, recorded for --full-command.
+	Path string
+	// Size is the total size in bytes of the regular files held open.
+	Size int64
+	// Peers counts the connections per remote endpoint.
+	Peers map[string]int
+	// Children is the number of child processes aggregated with --by-ppid.
+	Children int
+	// Uptime is how long the process has been running.
+	Uptime time.Duration
 }
 
 type ByCount []Process
@@ -34,88 +82,745 @@ func (s ByCount) Less(i, j int) bool {
 	return s[i].Count < s[j].Count
 }
 
+type BySize []Process
+
+func (s BySize) Len() int {
+	return len(s)
+}
+
+func (s BySize) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s BySize) Less(i, j int) bool {
+	return s[i].Size < s[j].Size
+}
+
+// Port represents a local port and the connections held on it.
+type Port struct {
+	Port      string
+	Listeners []string
+	Count     int
+}
+
+// Socket represents a network descriptor parsed from lsof's NAME column.
+type Socket struct {
+	Local  string
+	Remote string
+	State  string
+}
+
+// Header describes the layout of lsof's output as read from its header.
+type Header struct {
+	// TIDEnd is the end of the right aligned TID column, so a task line has
+	// a digit at TIDEnd-1. It is 0 when lsof reports no tasks.
+	TIDEnd int
+	// TaskColumns is the number of columns only filled in on task lines:
+	// TID, and TASKCMD on lsof releases that print it.
+	TaskColumns int
+}
+
+// parseHeader parses the header line of lsof's output.
+func parseHeader(text string) Header {
+	var h Header
+	if i := strings.Index(text, " TID "); i >= 0 {
+		h.TIDEnd = i + len(" TID")
+		h.TaskColumns = 1
+		if strings.Contains(text, " TASKCMD ") {
+			h.TaskColumns = 2
+		}
+	}
+	return h
+}
+
+// Line is a single line of lsof output split into fields.
+type Line struct {
+	Fields []string
+	// TID is set when the line describes a task (thread) of the process
+	// rather than the process itself. Only lsof on Linux reports tasks.
+	TID string
+	// shift is the number of task columns preceding USER on this line.
+	shift int
+}
+
+// parseLine parses a line of lsof's output laid out as described by h.
+func parseLine(h Header, text string) Line {
+	l := Line{Fields: strings.Fields(text)}
+	if h.TIDEnd > 0 && len(text) >= h.TIDEnd && text[h.TIDEnd-1] != ' ' && len(l.Fields) > 2 {
+		l.TID = l.Fields[2]
+		l.shift = h.TaskColumns
+	}
+	return l
+}
+
+// column returns the i-th field counting from the USER column, which is
+// shifted by the task columns on task lines. It returns an empty string
+// when the line has no such field.
+func (l Line) column(i int) string {
+	i += l.shift
+	if i+2 >= len(l.Fields) {
+		return ""
+	}
+	return l.Fields[i+2]
+}
+
+// User returns the owner of the process.
+func (l Line) User() string {
+	return l.column(0)
+}
+
+// FD returns the file descriptor, e.g. "3u", "cwd" or "txt".
+func (l Line) FD() string {
+	return l.column(1)
+}
+
+// Type returns the type of the file, e.g. "REG", "DIR" or "IPv4".
+func (l Line) Type() string {
+	return l.column(2)
+}
+
+// Size returns the size of a regular file. Only valid when lsof is run
+// with -s, which reports sizes instead of offsets.
+func (l Line) Size() int64 {
+	size, _ := strconv.ParseInt(l.column(4), 10, 64)
+	return size
+}
+
+// File returns the NAME column. Only valid for lines that fill in the
+// DEVICE, SIZE/OFF and NODE columns, such as regular files.
+func (l Line) File() string {
+	i := l.shift + 8
+	if i >= len(l.Fields) {
+		return ""
+	}
+	return strings.Join(l.Fields[i:], " ")
+}
+
 // lsofCmd represents the lsof command
 var lsofCmd = &cobra.Command{
 	Use:   "lsof",
 	Short: "Show process and file descriptors count",
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		if runtime.GOOS == "windows" {
-			log.Fatal("lsof not supported on windows")
+			return errors.New("lsof not supported on windows")
 		}
 
-		c := exec.Command("lsof")
+		if lsofByPort {
+			ports, err := scanPorts()
+			if err != nil {
+				return err
+			}
+			renderPorts(ports)
+			return nil
+		}
 
-		stderr, err := c.StdoutPipe()
-		if err != nil {
-			log.Fatal(err)
+		net := lsofNet || lsofShowPeers
+		var lsofArgs []string
+		if net {
+			lsofArgs = []string{"-i", "-n", "-P"}
 		}
-		if err := c.Start(); err != nil {
-			log.Fatal(err)
+		if lsofWithSize {
+			lsofArgs = append(lsofArgs, "-s")
 		}
 
 		processMap := make(map[string]*Process)
-		scanner := bufio.NewScanner(stderr)
-		scanner.Split(bufio.ScanLines)
-		for scanner.Scan() {
-			m := scanner.Text()
-			f := strings.Fields(m)
-
-			// Skip header
-			if len(f) == 3 {
-				continue
+		err := scanLsof(lsofArgs, func(l Line) {
+			// Tasks share the descriptor table of their process, so their
+			// lines only repeat the descriptors already counted.
+			if lsofMerge && l.TID != "" {
+				return
+			}
+			if lsofExclude && isSystemProcess(l.Fields[0], lsofSystem) {
+				return
+			}
+			if lsofExcludeRoot && l.User() == "root" {
+				return
 			}
 
-			pid := f[1]
-			name := f[0]
+			pid := l.Fields[1]
+			name := l.Fields[0]
 			process, ok := processMap[pid]
 			if !ok {
 				process = &Process{
-					PID:  pid,
-					Name: name,
+					PID:   pid,
+					Name:  name,
+					Peers: make(map[string]int),
 				}
 				processMap[pid] = process
 			}
 			process.Count++
+
+			// The first txt file of a process is its executable.
+			if lsofFullCommand && process.Path == "" && l.FD() == "txt" && l.Type() == "REG" {
+				process.Path = l.File()
+			}
+
+			// Only count files held open through a descriptor, not mapped
+			// libraries or the executable itself. Task lines repeat the
+			// descriptors of their process, so their sizes are skipped even
+			// when they are counted.
+			if lsofWithSize && l.TID == "" && l.Type() == "REG" && isDescriptor(l.FD()) {
+				process.Size += l.Size()
+			}
+
+			if lsofShowPeers {
+				if s := parseSocket(l.Fields); s.Remote != "" {
+					process.Peers[s.Remote]++
+				}
+			}
+		})
+		if err != nil {
+			return err
 		}
-		if err := c.Wait(); err != nil {
-			log.Fatal(err)
+
+		// Network files are listed without the txt descriptor, and the
+		// parents shown by --by-ppid may not be listed at all, so their
+		// paths are read in a separate pass.
+		var paths map[string]string
+		if lsofFullCommand && (net || lsofByParent) {
+			paths, err = commandPaths()
+			if err != nil {
+				return err
+			}
 		}
 
 		processes := make([]Process, len(processMap))
 		i := 0
 		for _, p := range processMap {
+			if p.Path == "" {
+				p.Path = paths[p.PID]
+			}
+			if p.Path != "" {
+				p.Name = p.Path
+			}
 			processes[i] = *p
 			i++
 		}
-		sort.Sort(ByCount(processes))
 
-		data := make([][]string, len(processes))
+		// Rows overlap with --by-ppid, so the totals are taken before the
+		// processes are grouped.
 		total := 0
-		for i, p := range processes {
-			data[i] = []string{p.PID, p.Name, strconv.Itoa(p.Count)}
+		var totalSize int64
+		for _, p := range processes {
 			total += p.Count
+			totalSize += p.Size
 		}
 
-		table := tablewriter.NewWriter(os.Stdout)
-		table.SetHeader([]string{"PID", "Name", "Descriptors"})
-		table.SetFooter([]string{"", "Total", strconv.Itoa(total)})
-		table.SetFooterAlignment(tablewriter.ALIGN_RIGHT)
-		table.SetBorder(false)
-		table.AppendBulk(data)
+		if lsofByParent {
+			processes, err = groupByParent(processes, paths)
+			if err != nil {
+				return err
+			}
+		}
+		if lsofUptime {
+			if err := readUptimes(processes); err != nil {
+				return err
+			}
+		}
+		if lsofWithSize {
+			sort.Sort(BySize(processes))
+		} else {
+			sort.Sort(ByCount(processes))
+		}
+
+		header := []string{"PID", "Name"}
+		if lsofByParent {
+			header = append(header, "Children")
+		}
+		if lsofShowPeers {
+			header = append(header, "Peers")
+		}
+		if lsofUptime {
+			header = append(header, "Uptime", "Per Hour")
+		}
+		header = append(header, "Descriptors")
+		if lsofWithSize {
+			header = append(header, "Size")
+		}
+
+		data := make([][]string, len(processes))
+		for i, p := range processes {
+			row := []string{p.PID, p.Name}
+			if lsofByParent {
+				row = append(row, strconv.Itoa(p.Children))
+			}
+			if lsofShowPeers {
+				row = append(row, topPeers(p.Peers))
+			}
+			if lsofUptime {
+				// A grouped row adds up the descriptors of processes that
+				// started at different times, so it has no meaningful rate.
+				rate := "-"
+				if !lsofByParent {
+					rate = perHour(p.Count, p.Uptime)
+				}
+				row = append(row, formatUptime(p.Uptime), rate)
+			}
+			row = append(row, strconv.Itoa(p.Count))
+			if lsofWithSize {
+				row = append(row, formatSize(p.Size))
+			}
+			data[i] = row
+		}
+
+		totals := []string{strconv.Itoa(total)}
+		if lsofWithSize {
+			totals = append(totals, formatSize(totalSize))
+		}
+
+		// Only highlight on a terminal so piped output stays plain text.
+		warn := lsofWarn > 0 && isTerminal(os.Stdout)
+
+		table := newTable(header, totals...)
+		for i, row := range data {
+			appendRow(table, row, warn && processes[i].Count >= lsofWarn)
+		}
 		table.Render()
+		return nil
 	},
 }
 
-func init() {
-	rootCmd.AddCommand(lsofCmd)
+// isSystemProcess reports whether name is one of the given system process
+// names, taking lsof's truncation of command names into account.
+func isSystemProcess(name string, system []string) bool {
+	for _, s := range system {
+		if name == s || (len(name) == commandWidth && strings.HasPrefix(s, name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// isDescriptor reports whether fd is a numbered file descriptor with its
+// access mode and lock suffixes, e.g. "3u", rather than a special entry
+// such as "cwd", "txt" or "mem".
+func isDescriptor(fd string) bool {
+	return fd != "" && fd[0] >= '0' && fd[0] <= '9'
+}
+
+// formatSize formats a size in bytes using units of 1024 bytes.
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return strconv.FormatInt(size, 10) + " B"
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// scanLsof runs lsof with the given arguments and calls fn for every line
+// of output, skipping the header. lsof exits with status 1 when nothing
+// matches, e.g. -i without any open sockets, which is not an error.
+func scanLsof(args []string, fn func(l Line)) error {
+	c := exec.Command("lsof", args...)
+	var stderr bytes.Buffer
+	c.Stderr = &stderr
+
+	stdout, err := c.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := c.Start(); err != nil {
+		return fmt.Errorf("failed to run lsof: %w", err)
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Split(bufio.ScanLines)
+	var header *Header
+	lines := 0
+	for scanner.Scan() {
+		if header == nil {
+			h := parseHeader(scanner.Text())
+			header = &h
+			continue
+		}
+		fn(parseLine(*header, scanner.Text()))
+		lines++
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read lsof output: %w", err)
+	}
+	if err := c.Wait(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 && lines == 0 {
+			return nil
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("lsof failed: %w: %s", err, msg)
+		}
+		return fmt.Errorf("lsof failed: %w", err)
+	}
+	return nil
+}
+
+// parseSocket parses the NAME column of an lsof -i line, which is either
+// "local" or "local->remote", optionally followed by the "(STATE)".
+func parseSocket(f []string) Socket {
+	var s Socket
+	name := f[len(f)-1]
+	if strings.HasPrefix(name, "(") && len(f) > 1 {
+		s.State = strings.Trim(name, "()")
+		name = f[len(f)-2]
+	}
+
+	parts := strings.SplitN(name, "->", 2)
+	s.Local = parts[0]
+	if len(parts) == 2 {
+		s.Remote = parts[1]
+	}
+	return s
+}
+
+// portOf returns the port portion of a host:port address.
+func portOf(addr string) string {
+	return addr[strings.LastIndex(addr, ":")+1:]
+}
+
+// groupByParent groups the processes by parent, see groupProcesses.
+// Parents that hold no descriptors themselves are named after their ps
+// command, or their executable path from paths with --full-command.
+func groupByParent(processes []Process, paths map[string]string) ([]Process, error) {
+	ps, err := psColumns("ppid", "comm")
+	if err != nil {
+		return nil, err
+	}
+
+	groups := groupProcesses(processes, ps)
+	for i, g := range groups {
+		if path := paths[g.PID]; path != "" {
+			groups[i].Name = path
+		}
+	}
+	return groups, nil
+}
+
+// groupProcesses groups the processes by parent using the ppid and comm
+// columns read from ps. Every parent of a listed process gets a row that
+// counts its own descriptors together with those of its direct children,
+// so a process that is both a parent and a child shows up in two rows. A
+// process whose parent can't be determined, e.g. because it exited in the
+// meantime, gets a row of its own.
+func groupProcesses(processes []Process, ps map[string][]string) []Process {
+	byPID := make(map[string]Process, len(processes))
+	for _, p := range processes {
+		byPID[p.PID] = p
+	}
+
+	groupMap := make(map[string]*Process)
+	group := func(pid string) *Process {
+		g, ok := groupMap[pid]
+		if ok {
+			return g
+		}
+
+		g = &Process{
+			PID:   pid,
+			Peers: make(map[string]int),
+		}
+		if p, ok := byPID[pid]; ok {
+			g.Name = p.Name
+			addProcess(g, p)
+		} else if c, ok := ps[pid]; ok {
+			g.Name = c[1]
+		}
+		groupMap[pid] = g
+		return g
+	}
+
+	for _, p := range processes {
+		c, ok := ps[p.PID]
+		if !ok {
+			group(p.PID)
+			continue
+		}
+		g := group(c[0])
+		g.Children++
+		addProcess(g, p)
+	}
+
+	groups := make([]Process, 0, len(groupMap))
+	for _, g := range groupMap {
+		groups = append(groups, *g)
+	}
+	return groups
+}
+
+// addProcess adds the descriptors of p to the group g.
+func addProcess(g *Process, p Process) {
+	g.Count += p.Count
+	g.Size += p.Size
+	for e, n := range p.Peers {
+		g.Peers[e] += n
+	}
+}
+
+// readUptimes sets the uptime of the processes from the elapsed time
+// reported by ps. Processes that exited in the meantime keep a zero uptime.
+func readUptimes(processes []Process) error {
+	ps, err := psColumns("etime")
+	if err != nil {
+		return err
+	}
+	for i, p := range processes {
+		if c, ok := ps[p.PID]; ok {
+			processes[i].Uptime = parseElapsed(c[0])
+		}
+	}
+	return nil
+}
+
+// parseElapsed parses the [[dd-]hh:]mm:ss elapsed time format of ps.
+func parseElapsed(etime string) time.Duration {
+	var days int
+	if i := strings.Index(etime, "-"); i >= 0 {
+		days, _ = strconv.Atoi(etime[:i])
+		etime = etime[i+1:]
+	}
+
+	d := time.Duration(days) * 24 * time.Hour
+	unit := time.Second
+	parts := strings.Split(etime, ":")
+	for i := len(parts) - 1; i >= 0; i-- {
+		n, _ := strconv.Atoi(parts[i])
+		d += time.Duration(n) * unit
+		unit *= 60
+	}
+	return d
+}
+
+// formatUptime formats an uptime as days and hours, hours and minutes, or
+// minutes, depending on its length.
+func formatUptime(d time.Duration) string {
+	days := int(d / (24 * time.Hour))
+	hours := int(d % (24 * time.Hour) / time.Hour)
+	minutes := int(d % time.Hour / time.Minute)
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd%dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh%dm", hours, minutes)
+	default:
+		return fmt.Sprintf("%dm", minutes)
+	}
+}
+
+// perHour formats the number of descriptors per hour of uptime. It is not
+// meaningful for processes that only just started.
+func perHour(count int, uptime time.Duration) string {
+	if uptime < time.Minute {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f", float64(count)/uptime.Hours())
+}
+
+// psColumns runs ps for all processes and returns the requested columns
+// keyed by PID. The last column may contain spaces, so it should be the
+// only one that can, e.g. comm.
+func psColumns(columns ...string) (map[string][]string, error) {
+	format := "pid="
+	for _, c := range columns {
+		format += "," + c + "="
+	}
+
+	out, err := exec.Command("ps", "-e", "-o", format).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run ps: %w", err)
+	}
+	return parsePS(string(out), len(columns)), nil
+}
+
+// parsePS parses the output of ps with the PID followed by n columns, the
+// last of which may contain spaces.
+func parsePS(out string, n int) map[string][]string {
+	rows := make(map[string][]string)
+	for _, line := range strings.Split(out, "\n") {
+		f := strings.Fields(line)
+		if len(f) < n+1 {
+			continue
+		}
+		last := strings.Join(f[n:], " ")
+		rows[f[0]] = append(f[1:n:n], last)
+	}
+	return rows
+}
+
+// topPeers formats the remote endpoints with the most connections, most
+// connected first.
+func topPeers(peers map[string]int) string {
+	endpoints := make([]string, 0, len(peers))
+	for e := range peers {
+		endpoints = append(endpoints, e)
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		if peers[endpoints[i]] != peers[endpoints[j]] {
+			return peers[endpoints[i]] > peers[endpoints[j]]
+		}
+		return endpoints[i] < endpoints[j]
+	})
+	if len(endpoints) > maxPeers {
+		endpoints = endpoints[:maxPeers]
+	}
+
+	for i, e := range endpoints {
+		endpoints[i] = e + " (" + strconv.Itoa(peers[e]) + ")"
+	}
+	return strings.Join(endpoints, ", ")
+}
 
-	// Here you will define your flags and configuration settings.
+// scanPorts aggregates the network descriptors by local port, counting
+// the connections on each port and the processes listening on it.
+func scanPorts() ([]Port, error) {
+	portMap := make(map[string]*Port)
+	err := scanLsof([]string{"-i", "-n", "-P"}, func(l Line) {
+		f := l.Fields
+		s := parseSocket(f)
+		port, ok := portMap[portOf(s.Local)]
+		if !ok {
+			port = &Port{Port: portOf(s.Local)}
+			portMap[port.Port] = port
+		}
+
+		if s.State == "LISTEN" {
+			for _, l := range port.Listeners {
+				if l == f[0] {
+					return
+				}
+			}
+			port.Listeners = append(port.Listeners, f[0])
+		}
+		if s.Remote != "" {
+			port.Count++
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ports := make([]Port, 0, len(portMap))
+	for _, p := range portMap {
+		ports = append(ports, *p)
+	}
+	sort.Slice(ports, func(i, j int) bool {
+		return ports[i].Count < ports[j].Count
+	})
+	return ports, nil
+}
+
+func renderPorts(ports []Port) {
+	data := make([][]string, len(ports))
+	total := 0
+	for i, p := range ports {
+		data[i] = []string{p.Port, strings.Join(p.Listeners, ", "), strconv.Itoa(p.Count)}
+		total += p.Count
+	}
+
+	table := newTable([]string{"Port", "Listener", "Connections"}, strconv.Itoa(total))
+	table.AppendBulk(data)
+	table.Render()
+}
 
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// lsofCmd.PersistentFlags().String("foo", "", "A help for foo")
+// appendRow appends a row to the table, in bold red when highlighted.
+func appendRow(table *tablewriter.Table, row []string, highlight bool) {
+	if !highlight {
+		table.Append(row)
+		return
+	}
+
+	colors := make([]tablewriter.Colors, len(row))
+	for i := range colors {
+		colors[i] = tablewriter.Colors{tablewriter.Bold, tablewriter.FgRedColor}
+	}
+	table.Rich(row, colors)
+}
+
+// isTerminal reports whether f is a terminal rather than a pipe or file.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// textColumns are the left aligned table columns, all others are numeric.
+var textColumns = map[string]bool{
+	"Name":     true,
+	"Listener": true,
+	"Peers":    true,
+}
+
+// newTable creates a table writer for stdout with the given header and a
+// footer showing the totals in the last columns. Column alignment is set
+// explicitly as highlighted rows would otherwise lose the alignment
+// tablewriter derives from the cell contents.
+func newTable(header []string, totals ...string) *tablewriter.Table {
+	footer := make([]string, len(header))
+	footer[len(footer)-len(totals)-1] = "Total"
+	copy(footer[len(footer)-len(totals):], totals)
+
+	align := make([]int, len(header))
+	for i, h := range header {
+		align[i] = tablewriter.ALIGN_RIGHT
+		if textColumns[h] {
+			align[i] = tablewriter.ALIGN_LEFT
+		}
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader(header)
+	table.SetFooter(footer)
+	table.SetFooterAlignment(tablewriter.ALIGN_RIGHT)
+	table.SetColumnAlignment(align)
+	table.SetBorder(false)
+	return table
+}
+
+// commandPaths reads the executable path of every process from its txt
+// descriptor in a single lsof pass, keyed by PID.
+func commandPaths() (map[string]string, error) {
+	out, err := exec.Command("lsof", "-d", "txt", "-Fpn").Output()
+	// lsof exits with status 1 when it can't read some of the processes,
+	// which leaves the paths of the others usable.
+	if err != nil && len(out) == 0 {
+		return nil, fmt.Errorf("failed to run lsof: %w", err)
+	}
+	return parseTxtPaths(string(out)), nil
+}
+
+// parseTxtPaths parses the pid and name fields of lsof -Fpn output and
+// returns the first name of each process. Names lsof failed to resolve,
+// e.g. for processes owned by other users, are skipped.
+func parseTxtPaths(out string) map[string]string {
+	paths := make(map[string]string)
+	pid := ""
+	for _, line := range strings.Split(out, "\n") {
+		switch {
+		case strings.HasPrefix(line, "p"):
+			pid = line[1:]
+		case strings.HasPrefix(line, "n"):
+			if _, ok := paths[pid]; ok || strings.Contains(line, "(readlink:") {
+				continue
+			}
+			paths[pid] = line[1:]
+		}
+	}
+	return paths
+}
+
+func init() {
+	rootCmd.AddCommand(lsofCmd)
 
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// lsofCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	lsofCmd.Flags().BoolVar(&lsofFullCommand, "full-command", false, "Show the full executable path instead of the command name")
+	lsofCmd.Flags().BoolVar(&lsofNet, "net", false, "Only count network descriptors")
+	lsofCmd.Flags().BoolVar(&lsofByPort, "by-port", false, "Aggregate network connections per local port")
+	lsofCmd.Flags().BoolVar(&lsofMerge, "merge-threads", false, "Don't count the descriptors listed for each thread of a process")
+	lsofCmd.Flags().BoolVar(&lsofShowPeers, "show-peers", false, "Show the remote endpoints each process is connected to most (implies --net)")
+	lsofCmd.Flags().BoolVar(&lsofExclude, "exclude-system", false, "Exclude system processes, see --system-processes")
+	lsofCmd.Flags().StringSliceVar(&lsofSystem, "system-processes", systemProcesses, "Command names excluded by --exclude-system")
+	lsofCmd.Flags().BoolVar(&lsofExcludeRoot, "exclude-root", false, "Exclude processes owned by root")
+	lsofCmd.Flags().IntVar(&lsofWarn, "warn", 0, "Highlight processes with at least this many descriptors when writing to a terminal")
+	lsofCmd.Flags().BoolVar(&lsofByParent, "by-ppid", false, "Show each parent process with the descriptors of its direct children")
+	lsofCmd.Flags().BoolVar(&lsofUptime, "uptime", false, "Show each process's uptime and descriptors per hour of uptime (with --by-ppid, the parent's uptime and no rate)")
+	lsofCmd.Flags().BoolVar(&lsofWithSize, "with-size", false, "Show and sort by the total size of the regular files each process holds open")
 }
@@ -0,0 +1,305 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+const (
+	linuxHeader  = "COMMAND    PID  TID TASKCMD     USER   FD      TYPE             DEVICE  SIZE/OFF    NODE NAME"
+	darwinHeader = "COMMAND     PID USER   FD      TYPE             DEVICE  SIZE/OFF     NODE NAME"
+	// Older lsof releases print the TID column without TASKCMD.
+	oldLinuxHeader = "COMMAND     PID   TID       USER   FD      TYPE             DEVICE SIZE/OFF    NODE NAME"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		line   string
+		tid    string
+		user   string
+		fd     string
+		typ    string
+		size   int64
+		file   string
+	}{
+		{
+			name:   "linux process",
+			header: linuxHeader,
+			line:   "bash      8391                  root  cwd       DIR              254,0      4096 1114139 /home/me",
+			user:   "root",
+			fd:     "cwd",
+			typ:    "DIR",
+			size:   4096,
+		},
+		{
+			name:   "linux task",
+			header: linuxHeader,
+			line:   "mysqld    1234 1240 ib_io_wr  mysql  txt   unknown                                      /proc/1234/task/1240/exe (readlink: Permission denied)",
+			tid:    "1240",
+			user:   "mysql",
+			fd:     "txt",
+			typ:    "unknown",
+		},
+		{
+			name:   "linux process with numeric user",
+			header: linuxHeader,
+			line:   "bash      8391                  1000    3u      REG              254,0      4096 1114139 /tmp/log",
+			user:   "1000",
+			fd:     "3u",
+			typ:    "REG",
+			size:   4096,
+			file:   "/tmp/log",
+		},
+		{
+			name:   "linux task with spaces in name",
+			header: linuxHeader,
+			line:   "code      4711 4712 code          me  txt       REG              254,0 152375816 1318912 /opt/VS Code/code (deleted)",
+			tid:    "4712",
+			user:   "me",
+			fd:     "txt",
+			typ:    "REG",
+			size:   152375816,
+			file:   "/opt/VS Code/code (deleted)",
+		},
+		{
+			name:   "old linux process",
+			header: oldLinuxHeader,
+			line:   "mysqld     1234            mysql  cwd       DIR              254,0     4096       2 /var/lib/mysql",
+			user:   "mysql",
+			fd:     "cwd",
+			typ:    "DIR",
+			size:   4096,
+		},
+		{
+			name:   "old linux task",
+			header: oldLinuxHeader,
+			line:   "mysqld     1234  1240      mysql   12u      REG              254,0  1048576  131075 /var/lib/mysql/ibdata1",
+			tid:    "1240",
+			user:   "mysql",
+			fd:     "12u",
+			typ:    "REG",
+			size:   1048576,
+			file:   "/var/lib/mysql/ibdata1",
+		},
+		{
+			name:   "darwin process",
+			header: darwinHeader,
+			line:   "launchd       1 root  txt       REG               1,18    929520 1152921500312428460 /sbin/launchd",
+			user:   "root",
+			fd:     "txt",
+			typ:    "REG",
+			size:   929520,
+			file:   "/sbin/launchd",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := parseLine(parseHeader(tt.header), tt.line)
+			if l.TID != tt.tid {
+				t.Errorf("TID = %q, want %q", l.TID, tt.tid)
+			}
+			if l.User() != tt.user {
+				t.Errorf("User() = %q, want %q", l.User(), tt.user)
+			}
+			if l.FD() != tt.fd {
+				t.Errorf("FD() = %q, want %q", l.FD(), tt.fd)
+			}
+			if l.Type() != tt.typ {
+				t.Errorf("Type() = %q, want %q", l.Type(), tt.typ)
+			}
+			if l.Size() != tt.size {
+				t.Errorf("Size() = %d, want %d", l.Size(), tt.size)
+			}
+			if tt.file != "" && l.File() != tt.file {
+				t.Errorf("File() = %q, want %q", l.File(), tt.file)
+			}
+		})
+	}
+}
+
+func TestParseSocket(t *testing.T) {
+	tests := []struct {
+		line string
+		want Socket
+		port string
+	}{
+		{
+			line: "postgres  921 postgres    5u  IPv4  21894      0t0  TCP 127.0.0.1:5432 (LISTEN)",
+			want: Socket{Local: "127.0.0.1:5432", State: "LISTEN"},
+			port: "5432",
+		},
+		{
+			line: "python3  3310       me    7u  IPv4  48213      0t0  TCP 127.0.0.1:41822->127.0.0.1:5432 (ESTABLISHED)",
+			want: Socket{Local: "127.0.0.1:41822", Remote: "127.0.0.1:5432", State: "ESTABLISHED"},
+			port: "41822",
+		},
+		{
+			line: "node     4242    me   23u  IPv6  0x1a2b      0t0  TCP [::1]:3000->[::1]:51234 (ESTABLISHED)",
+			want: Socket{Local: "[::1]:3000", Remote: "[::1]:51234", State: "ESTABLISHED"},
+			port: "3000",
+		},
+		{
+			line: "nginx     812  root    6u  IPv6  20817      0t0  TCP *:80 (LISTEN)",
+			want: Socket{Local: "*:80", State: "LISTEN"},
+			port: "80",
+		},
+		{
+			line: "avahi-dae 611 avahi   12u  IPv4  18341      0t0  UDP *:5353",
+			want: Socket{Local: "*:5353"},
+			port: "5353",
+		},
+	}
+
+	for _, tt := range tests {
+		l := parseLine(parseHeader(darwinHeader), tt.line)
+		got := parseSocket(l.Fields)
+		if got != tt.want {
+			t.Errorf("parseSocket(%q) = %+v, want %+v", tt.line, got, tt.want)
+		}
+		if port := portOf(got.Local); port != tt.port {
+			t.Errorf("portOf(%q) = %q, want %q", got.Local, port, tt.port)
+		}
+	}
+}
+
+func TestParseTxtPaths(t *testing.T) {
+	out := "p1\n" +
+		"n/proc/1/exe (readlink: Permission denied)\n" +
+		"p311\n" +
+		"n/Applications/Visual Studio Code.app/Contents/MacOS/Electron\n" +
+		"n/usr/lib/dyld\n" +
+		"p8391\n" +
+		"n/usr/bin/bash\n"
+
+	want := map[string]string{
+		"311":  "/Applications/Visual Studio Code.app/Contents/MacOS/Electron",
+		"8391": "/usr/bin/bash",
+	}
+	if got := parseTxtPaths(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseTxtPaths() = %v, want %v", got, want)
+	}
+}
+
+func TestIsSystemProcess(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"systemd", true},
+		{"systemd-j", true},
+		{"systemd-x", false},
+		{"launchd", true},
+		{"launch", false},
+		{"bash", false},
+	}
+
+	for _, tt := range tests {
+		if got := isSystemProcess(tt.name, systemProcesses); got != tt.want {
+			t.Errorf("isSystemProcess(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseElapsed(t *testing.T) {
+	tests := []struct {
+		etime string
+		want  time.Duration
+	}{
+		{"00:05", 5 * time.Second},
+		{"12:27", 12*time.Minute + 27*time.Second},
+		{"03:12:27", 3*time.Hour + 12*time.Minute + 27*time.Second},
+		{"2-03:12:27", 51*time.Hour + 12*time.Minute + 27*time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := parseElapsed(tt.etime); got != tt.want {
+			t.Errorf("parseElapsed(%q) = %v, want %v", tt.etime, got, tt.want)
+		}
+	}
+}
+
+func TestFormatUptime(t *testing.T) {
+	tests := []struct {
+		uptime time.Duration
+		want   string
+	}{
+		{5 * time.Second, "0m"},
+		{16 * time.Minute, "16m"},
+		{3*time.Hour + 12*time.Minute + 27*time.Second, "3h12m"},
+		{51*time.Hour + 12*time.Minute, "2d3h"},
+	}
+
+	for _, tt := range tests {
+		if got := formatUptime(tt.uptime); got != tt.want {
+			t.Errorf("formatUptime(%v) = %q, want %q", tt.uptime, got, tt.want)
+		}
+	}
+}
+
+func TestParsePS(t *testing.T) {
+	out := "    1     0 systemd\n" +
+		"    2     0 kthreadd\n" +
+		"  311     1 /Applications/Visual Studio Code.app/Contents/MacOS/Electron\n"
+
+	want := map[string][]string{
+		"1":   {"0", "systemd"},
+		"2":   {"0", "kthreadd"},
+		"311": {"1", "/Applications/Visual Studio Code.app/Contents/MacOS/Electron"},
+	}
+	if got := parsePS(out, 2); !reflect.DeepEqual(got, want) {
+		t.Errorf("parsePS() = %v, want %v", got, want)
+	}
+
+	want = map[string][]string{
+		"1": {"12:27"},
+	}
+	if got := parsePS("    1       12:27\n", 1); !reflect.DeepEqual(got, want) {
+		t.Errorf("parsePS() = %v, want %v", got, want)
+	}
+}
+
+func TestGroupProcesses(t *testing.T) {
+	processes := []Process{
+		{PID: "100", Name: "bash", Count: 9},
+		{PID: "200", Name: "/usr/bin/make", Count: 5},
+		{PID: "300", Name: "cc1", Count: 10},
+		{PID: "301", Name: "cc1", Count: 20},
+		{PID: "400", Name: "sshd", Count: 3},
+		{PID: "500", Name: "gone", Count: 1},
+	}
+	ps := map[string][]string{
+		"1":   {"0", "init"},
+		"100": {"1", "bash"},
+		"200": {"100", "make"},
+		"300": {"200", "cc1"},
+		"301": {"200", "cc1"},
+		"400": {"1", "sshd"},
+	}
+
+	want := map[string]Process{
+		"1":   {PID: "1", Name: "init", Count: 12, Children: 2},
+		"100": {PID: "100", Name: "bash", Count: 14, Children: 1},
+		"200": {PID: "200", Name: "/usr/bin/make", Count: 35, Children: 2},
+		"500": {PID: "500", Name: "gone", Count: 1},
+	}
+
+	groups := groupProcesses(processes, ps)
+	if len(groups) != len(want) {
+		t.Fatalf("got %d groups, want %d", len(groups), len(want))
+	}
+	for _, g := range groups {
+		w, ok := want[g.PID]
+		if !ok {
+			t.Errorf("unexpected group %+v", g)
+			continue
+		}
+		if g.Name != w.Name || g.Count != w.Count || g.Children != w.Children {
+			t.Errorf("group %s = {Name: %q, Count: %d, Children: %d}, want {Name: %q, Count: %d, Children: %d}",
+				g.PID, g.Name, g.Count, g.Children, w.Name, w.Count, w.Children)
+		}
+	}
+}
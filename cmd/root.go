@@ -3,12 +3,19 @@ package cmd
 import (
 	"os"
 
+	"github.com/debugged/deb/buildinfo"
 	"github.com/spf13/cobra"
 )
 
 var rootCmd = &cobra.Command{
-	Use:   "deb",
-	Short: "Debugged development cli",
+	Use:     "deb",
+	Short:   "Debugged development cli",
+	Version: buildinfo.Version,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		// Flags and args have been parsed at this point, so any error the
+		// command returns is a runtime failure and not a usage error.
+		cmd.SilenceUsage = true
+	},
 }
 
 func Execute() {
@@ -1,33 +1,45 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
 
 	"github.com/debugged/deb/buildinfo"
 	"github.com/spf13/cobra"
 )
 
+var versionJSON bool
+
 // versionCmd represents the version command
 var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Display this binary's version, build time, and git hash of this build",
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Printf("Version:    %s\n", buildinfo.Version)
-		fmt.Printf("Git Hash:   %s\n", buildinfo.GitHash)
-		fmt.Printf("Build Time: %s\n", buildinfo.Time)
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if versionJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(struct {
+				Version   string `json:"version"`
+				GitHash   string `json:"git_hash"`
+				BuildTime string `json:"build_time"`
+			}{buildinfo.Version, buildinfo.GitHash, buildinfo.Time})
+		}
+
+		fmt.Print(versionText())
+		return nil
 	},
 }
 
+// versionText formats the build information for humans.
+func versionText() string {
+	return fmt.Sprintf("Version:    %s\nGit Hash:   %s\nBuild Time: %s\n",
+		buildinfo.Version, buildinfo.GitHash, buildinfo.Time)
+}
+
 func init() {
 	rootCmd.AddCommand(versionCmd)
+	rootCmd.SetVersionTemplate(versionText())
 
-	// Here you will define your flags and configuration settings.
-
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// versionCmd.PersistentFlags().String("foo", "", "A help for foo")
-
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// versionCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	versionCmd.Flags().BoolVar(&versionJSON, "json", false, "Print the build information as JSON")
 }